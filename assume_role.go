@@ -0,0 +1,167 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/service/sts"
+)
+
+var (
+	errMissingRoleArn           = errors.New("role_arn is required")
+	errMissingWebIdentityParams = errors.New("role_arn and either web_identity_token or token_file are required")
+)
+
+// defaultAssumeRoleSessionName is used when callers don't supply one.
+const defaultAssumeRoleSessionName = "iamtestapp"
+
+// defaultAssumeRoleDurationSeconds is the shortest session duration STS
+// accepts for AssumeRole / AssumeRoleWithWebIdentity.
+const defaultAssumeRoleDurationSeconds = 900
+
+// assumeRoleRequest is accepted either as a JSON body or as query string
+// parameters; query parameters take precedence when both are present.
+type assumeRoleRequest struct {
+	RoleArn              string `json:"role_arn"`
+	SessionName          string `json:"session_name"`
+	DurationSeconds      int64  `json:"duration"`
+	WebIdentityToken     string `json:"web_identity_token"`
+	WebIdentityTokenFile string `json:"token_file"`
+}
+
+func parseAssumeRoleRequest(r *http.Request) assumeRoleRequest {
+	var req assumeRoleRequest
+	if r.Body != nil {
+		json.NewDecoder(r.Body).Decode(&req)
+	}
+
+	q := r.URL.Query()
+	if v := q.Get("role_arn"); v != "" {
+		req.RoleArn = v
+	}
+	if v := q.Get("session_name"); v != "" {
+		req.SessionName = v
+	}
+	if v := q.Get("duration"); v != "" {
+		if d, err := strconv.ParseInt(v, 10, 64); err == nil {
+			req.DurationSeconds = d
+		}
+	}
+	if v := q.Get("web_identity_token"); v != "" {
+		req.WebIdentityToken = v
+	}
+	if v := q.Get("token_file"); v != "" {
+		req.WebIdentityTokenFile = v
+	}
+
+	if req.SessionName == "" {
+		req.SessionName = defaultAssumeRoleSessionName
+	}
+	if req.DurationSeconds == 0 {
+		req.DurationSeconds = defaultAssumeRoleDurationSeconds
+	}
+	return req
+}
+
+func newSTSClient(loggingLevel aws.LogLevelType) (*sts.STS, error) {
+	s, err := session.NewSession(&aws.Config{
+		MaxRetries:                    aws.Int(1),
+		CredentialsChainVerboseErrors: aws.Bool(true),
+		LogLevel:                      aws.LogLevel(loggingLevel),
+		Logger:                        aws.NewDefaultLogger(),
+	})
+	if err != nil {
+		return nil, err
+	}
+	instrumentSession(s)
+	return sts.New(s), nil
+}
+
+// assumeRoleHandler lets an operator verify that the pod's ambient
+// credentials can assume role_arn, which is the cross-account role
+// chaining case operators need to debug from inside the pod.
+func assumeRoleHandler(loggingLevel aws.LogLevelType) http.HandlerFunc {
+	return func(rw http.ResponseWriter, r *http.Request) {
+		req := parseAssumeRoleRequest(r)
+		if req.RoleArn == "" {
+			rw.WriteHeader(http.StatusBadRequest)
+			errorHandler(rw, errMissingRoleArn)
+			return
+		}
+
+		svc, err := newSTSClient(loggingLevel)
+		if err != nil {
+			errorHandler(rw, err)
+			return
+		}
+
+		result, err := svc.AssumeRole(&sts.AssumeRoleInput{
+			RoleArn:         aws.String(req.RoleArn),
+			RoleSessionName: aws.String(req.SessionName),
+			DurationSeconds: aws.Int64(req.DurationSeconds),
+		})
+		if err != nil {
+			errorHandler(rw, err)
+			return
+		}
+
+		json.NewEncoder(rw).Encode(result)
+	}
+}
+
+// assumeRoleWebIdentityHandler exercises the IRSA token exchange. When
+// role_arn or token_file aren't supplied, it falls back to the
+// AWS_ROLE_ARN / AWS_WEB_IDENTITY_TOKEN_FILE env vars the EKS pod
+// identity webhook injects.
+func assumeRoleWebIdentityHandler(loggingLevel aws.LogLevelType) http.HandlerFunc {
+	return func(rw http.ResponseWriter, r *http.Request) {
+		req := parseAssumeRoleRequest(r)
+		if req.RoleArn == "" {
+			req.RoleArn = os.Getenv("AWS_ROLE_ARN")
+		}
+		if req.WebIdentityTokenFile == "" {
+			req.WebIdentityTokenFile = os.Getenv("AWS_WEB_IDENTITY_TOKEN_FILE")
+		}
+
+		token := req.WebIdentityToken
+		if token == "" && req.WebIdentityTokenFile != "" {
+			b, err := os.ReadFile(req.WebIdentityTokenFile)
+			if err != nil {
+				errorHandler(rw, err)
+				return
+			}
+			token = strings.TrimSpace(string(b))
+		}
+
+		if req.RoleArn == "" || token == "" {
+			rw.WriteHeader(http.StatusBadRequest)
+			errorHandler(rw, errMissingWebIdentityParams)
+			return
+		}
+
+		svc, err := newSTSClient(loggingLevel)
+		if err != nil {
+			errorHandler(rw, err)
+			return
+		}
+
+		result, err := svc.AssumeRoleWithWebIdentity(&sts.AssumeRoleWithWebIdentityInput{
+			RoleArn:          aws.String(req.RoleArn),
+			RoleSessionName:  aws.String(req.SessionName),
+			WebIdentityToken: aws.String(token),
+			DurationSeconds:  aws.Int64(req.DurationSeconds),
+		})
+		if err != nil {
+			errorHandler(rw, err)
+			return
+		}
+
+		json.NewEncoder(rw).Encode(result)
+	}
+}
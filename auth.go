@@ -0,0 +1,167 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/credentials"
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/service/sts"
+)
+
+// callerARNTTL controls how long a verified bearer token's caller ARN
+// is cached before it must be re-verified against STS.
+const callerARNTTL = 5 * time.Minute
+
+type contextKey string
+
+const callerARNContextKey contextKey = "callerARN"
+
+type callerCacheEntry struct {
+	arn       string
+	expiresAt time.Time
+}
+
+// callerCache memoizes the GetCallerIdentity lookup for a bearer token so
+// every authenticated request doesn't round-trip to STS.
+type callerCache struct {
+	mu      sync.Mutex
+	entries map[string]callerCacheEntry
+}
+
+func newCallerCache() *callerCache {
+	c := &callerCache{entries: make(map[string]callerCacheEntry)}
+	go c.sweepExpired()
+	return c
+}
+
+func (c *callerCache) get(token string) (string, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	entry, ok := c.entries[token]
+	if !ok {
+		return "", false
+	}
+	if time.Now().After(entry.expiresAt) {
+		delete(c.entries, token)
+		return "", false
+	}
+	return entry.arn, true
+}
+
+// sweepExpired periodically drops expired entries that were never looked
+// up again after caching, so the map tracks live tokens rather than every
+// token ever seen by a long-lived pod.
+func (c *callerCache) sweepExpired() {
+	ticker := time.NewTicker(callerARNTTL)
+	defer ticker.Stop()
+
+	for now := range ticker.C {
+		c.mu.Lock()
+		for token, entry := range c.entries {
+			if now.After(entry.expiresAt) {
+				delete(c.entries, token)
+			}
+		}
+		c.mu.Unlock()
+	}
+}
+
+func (c *callerCache) put(token, arn string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.entries[token] = callerCacheEntry{
+		arn:       arn,
+		expiresAt: time.Now().Add(callerARNTTL),
+	}
+}
+
+// bearerCredentials splits a "accessKeyID:secretAccessKey:sessionToken"
+// bearer token into its AWS credential parts. The session token segment
+// is optional.
+func bearerCredentials(token string) (accessKeyID, secretAccessKey, sessionToken string, ok bool) {
+	parts := strings.SplitN(token, ":", 3)
+	if len(parts) < 2 || parts[0] == "" || parts[1] == "" {
+		return "", "", "", false
+	}
+	if len(parts) == 3 {
+		sessionToken = parts[2]
+	}
+	return parts[0], parts[1], sessionToken, true
+}
+
+// verifyCaller exchanges a bearer token for the ARN of the identity it
+// belongs to, consulting the cache before calling sts.GetCallerIdentity.
+func verifyCaller(cache *callerCache, loggingLevel aws.LogLevelType, token string) (string, error) {
+	if arn, ok := cache.get(token); ok {
+		return arn, nil
+	}
+
+	accessKeyID, secretAccessKey, sessionToken, ok := bearerCredentials(token)
+	if !ok {
+		return "", fmt.Errorf("malformed bearer token")
+	}
+
+	s, err := session.NewSessionWithOptions(session.Options{
+		Config: aws.Config{
+			Credentials:                   credentials.NewStaticCredentials(accessKeyID, secretAccessKey, sessionToken),
+			MaxRetries:                    aws.Int(1),
+			CredentialsChainVerboseErrors: aws.Bool(true),
+			LogLevel:                      aws.LogLevel(loggingLevel),
+			Logger:                        aws.NewDefaultLogger(),
+		},
+	})
+	if err != nil {
+		return "", err
+	}
+	instrumentSession(s)
+
+	result, err := sts.New(s).GetCallerIdentity(&sts.GetCallerIdentityInput{})
+	if err != nil {
+		return "", err
+	}
+
+	arn := aws.StringValue(result.Arn)
+	cache.put(token, arn)
+	return arn, nil
+}
+
+// authMiddleware rejects requests that don't carry a bearer token that
+// verifies against STS, and injects the resulting caller ARN into the
+// request context for downstream handlers.
+func authMiddleware(cache *callerCache, loggingLevel aws.LogLevelType) Middleware {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+			authHeader := r.Header.Get("Authorization")
+			if !strings.HasPrefix(authHeader, "Bearer ") {
+				rw.WriteHeader(http.StatusUnauthorized)
+				errorHandler(rw, fmt.Errorf("missing or malformed Authorization header"))
+				return
+			}
+			token := strings.TrimPrefix(authHeader, "Bearer ")
+
+			arn, err := verifyCaller(cache, loggingLevel, token)
+			if err != nil {
+				rw.WriteHeader(http.StatusUnauthorized)
+				errorHandler(rw, err)
+				return
+			}
+
+			ctx := context.WithValue(r.Context(), callerARNContextKey, arn)
+			next.ServeHTTP(rw, r.WithContext(ctx))
+		})
+	}
+}
+
+// callerARNFromContext returns the caller ARN stashed by authMiddleware.
+func callerARNFromContext(ctx context.Context) (string, bool) {
+	arn, ok := ctx.Value(callerARNContextKey).(string)
+	return arn, ok
+}
@@ -0,0 +1,105 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/service/iam"
+)
+
+// simulatePrincipalPolicyRequest mirrors the subset of
+// iam.SimulatePrincipalPolicyInput that callers are expected to supply;
+// PolicySourceArn defaults to the authenticated caller's ARN when omitted.
+type simulatePrincipalPolicyRequest struct {
+	PolicySourceArn string   `json:"policySourceArn"`
+	ActionNames     []string `json:"actionNames"`
+	ResourceArns    []string `json:"resourceArns"`
+}
+
+// simulateCustomPolicyRequest mirrors the subset of
+// iam.SimulateCustomPolicyInput that callers are expected to supply.
+type simulateCustomPolicyRequest struct {
+	PolicyInputList []string `json:"policyInputList"`
+	ActionNames     []string `json:"actionNames"`
+	ResourceArns    []string `json:"resourceArns"`
+}
+
+func newIAMClient(loggingLevel aws.LogLevelType) (*iam.IAM, error) {
+	s, err := session.NewSession(&aws.Config{
+		MaxRetries:                    aws.Int(1),
+		CredentialsChainVerboseErrors: aws.Bool(true),
+		LogLevel:                      aws.LogLevel(loggingLevel),
+		Logger:                        aws.NewDefaultLogger(),
+	})
+	if err != nil {
+		return nil, err
+	}
+	instrumentSession(s)
+	return iam.New(s), nil
+}
+
+func simulatePrincipalPolicyHandler(loggingLevel aws.LogLevelType) http.HandlerFunc {
+	return func(rw http.ResponseWriter, r *http.Request) {
+		var req simulatePrincipalPolicyRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			rw.WriteHeader(http.StatusBadRequest)
+			errorHandler(rw, err)
+			return
+		}
+
+		if req.PolicySourceArn == "" {
+			if arn, ok := callerARNFromContext(r.Context()); ok {
+				req.PolicySourceArn = arn
+			}
+		}
+
+		svc, err := newIAMClient(loggingLevel)
+		if err != nil {
+			errorHandler(rw, err)
+			return
+		}
+
+		result, err := svc.SimulatePrincipalPolicy(&iam.SimulatePrincipalPolicyInput{
+			PolicySourceArn: aws.String(req.PolicySourceArn),
+			ActionNames:     aws.StringSlice(req.ActionNames),
+			ResourceArns:    aws.StringSlice(req.ResourceArns),
+		})
+		if err != nil {
+			errorHandler(rw, err)
+			return
+		}
+
+		json.NewEncoder(rw).Encode(result)
+	}
+}
+
+func simulateCustomPolicyHandler(loggingLevel aws.LogLevelType) http.HandlerFunc {
+	return func(rw http.ResponseWriter, r *http.Request) {
+		var req simulateCustomPolicyRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			rw.WriteHeader(http.StatusBadRequest)
+			errorHandler(rw, err)
+			return
+		}
+
+		svc, err := newIAMClient(loggingLevel)
+		if err != nil {
+			errorHandler(rw, err)
+			return
+		}
+
+		result, err := svc.SimulateCustomPolicy(&iam.SimulateCustomPolicyInput{
+			PolicyInputList: aws.StringSlice(req.PolicyInputList),
+			ActionNames:     aws.StringSlice(req.ActionNames),
+			ResourceArns:    aws.StringSlice(req.ResourceArns),
+		})
+		if err != nil {
+			errorHandler(rw, err)
+			return
+		}
+
+		json.NewEncoder(rw).Encode(result)
+	}
+}
@@ -0,0 +1,141 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"os"
+	"runtime"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/rs/zerolog"
+)
+
+// requestIDPrefixEnv names the environment variable used to prefix
+// generated request IDs, e.g. "iamtestapp" -> "iamtestapp-1a2b3c4d5e6f".
+const requestIDPrefixEnv = "REQUEST_ID_PREFIX"
+
+// maxTracebackFrames bounds how many stack frames are captured when a
+// handler panics.
+const maxTracebackFrames = 32
+
+var requestIDLog = zerolog.New(os.Stdout).With().Timestamp().Logger()
+
+// requestIDGenerator produces monotonic, process-unique request IDs: the
+// counter is seeded from the current nanosecond timestamp so IDs don't
+// collide with a previous run of the process, then incremented under a
+// mutex on every call so IDs strictly increase within the process.
+type requestIDGenerator struct {
+	mu      sync.Mutex
+	counter uint64
+	prefix  string
+}
+
+func newRequestIDGenerator(prefix string) *requestIDGenerator {
+	return &requestIDGenerator{
+		counter: uint64(time.Now().UnixNano()),
+		prefix:  prefix,
+	}
+}
+
+func (g *requestIDGenerator) next() string {
+	g.mu.Lock()
+	g.counter++
+	n := g.counter
+	g.mu.Unlock()
+
+	id := strconv.FormatUint(n, 36)
+	if g.prefix == "" {
+		return id
+	}
+	return g.prefix + "-" + id
+}
+
+type requestContextKey string
+
+const requestIDContextKey requestContextKey = "requestID"
+
+func requestIDFromContext(ctx context.Context) string {
+	id, _ := ctx.Value(requestIDContextKey).(string)
+	return id
+}
+
+// captureTraceback formats up to maxTracebackFrames stack frames (skipping
+// the capturing function itself) as "func@file:line" lines.
+func captureTraceback(skip int) []string {
+	pcs := make([]uintptr, maxTracebackFrames)
+	n := runtime.Callers(skip+2, pcs)
+	framesIter := runtime.CallersFrames(pcs[:n])
+
+	frames := make([]string, 0, n)
+	for {
+		frame, more := framesIter.Next()
+		frames = append(frames, frame.Function+"@"+frame.File+":"+strconv.Itoa(frame.Line))
+		if !more {
+			break
+		}
+	}
+	return frames
+}
+
+// structuredLoggingMiddleware replaces the old ad-hoc loggingMiddleware
+// with JSON request logs (method, path, status, duration_ms, remote_addr,
+// request_id) and, on panic, a captured goroutine traceback logged before
+// a 500 is written with the request ID in the JSONError body.
+func structuredLoggingMiddleware(logger zerolog.Logger, idGen *requestIDGenerator) Middleware {
+	return func(h http.Handler) http.Handler {
+		return http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+			requestID := idGen.next()
+			rw.Header().Set("X-Request-Id", requestID)
+
+			ctx := context.WithValue(r.Context(), requestIDContextKey, requestID)
+			r = r.WithContext(ctx)
+
+			start := time.Now()
+			wrapped := wrapResponseWriter(rw)
+
+			defer func() {
+				if err := recover(); err != nil {
+					traceback := captureTraceback(2)
+					logger.Error().
+						Str("method", r.Method).
+						Str("path", r.URL.Path).
+						Str("remote_addr", r.RemoteAddr).
+						Str("request_id", requestID).
+						Interface("panic", err).
+						Strs("traceback", traceback).
+						Msg("panic recovered")
+
+					wrapped.WriteHeader(http.StatusInternalServerError)
+					json.NewEncoder(wrapped).Encode(&JSONError{Error: "internal server error", RequestID: requestID})
+
+					httpRequestDuration.
+						WithLabelValues(httpMethodLabel(r.Method), httpPathLabel(r.URL.Path), httpStatusLabel(wrapped.Status())).
+						Observe(time.Since(start).Seconds())
+				}
+			}()
+
+			h.ServeHTTP(wrapped, r)
+
+			httpRequestDuration.
+				WithLabelValues(httpMethodLabel(r.Method), httpPathLabel(r.URL.Path), httpStatusLabel(wrapped.Status())).
+				Observe(time.Since(start).Seconds())
+
+			logger.Info().
+				Str("method", r.Method).
+				Str("path", r.URL.Path).
+				Int("status", wrapped.Status()).
+				Float64("duration_ms", float64(time.Since(start))/float64(time.Millisecond)).
+				Str("remote_addr", r.RemoteAddr).
+				Str("request_id", requestID).
+				Msg("request handled")
+		})
+	}
+}
+
+func requestIDPrefixFromEnv() string {
+	return strings.TrimSpace(os.Getenv(requestIDPrefixEnv))
+}
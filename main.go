@@ -9,16 +9,17 @@ import (
 	"os"
 	"os/signal"
 	"strconv"
-	"time"
 
 	"github.com/aws/aws-sdk-go/aws"
 	"github.com/aws/aws-sdk-go/aws/awserr"
 	"github.com/aws/aws-sdk-go/aws/session"
 	"github.com/aws/aws-sdk-go/service/sts"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
 )
 
 type JSONError struct {
-	Error string `json:"error"`
+	Error     string `json:"error"`
+	RequestID string `json:"request_id,omitempty"`
 }
 
 func errorHandler(w io.Writer, err error) {
@@ -34,15 +35,6 @@ func errorHandler(w io.Writer, err error) {
 	json.NewEncoder(w).Encode(je)
 }
 
-type HTTPRequestInfo struct {
-	Method    string  `json:"method"`
-	URL       string  `json:"url"`
-	Referer   string  `json:"referer"`
-	UserAgent string  `json:"userAgent"`
-	Status    int     `json:"status"`
-	Duration  float64 `json:"duration"`
-}
-
 type responseWriter struct {
 	http.ResponseWriter
 	status      int
@@ -50,7 +42,7 @@ type responseWriter struct {
 }
 
 func wrapResponseWriter(w http.ResponseWriter) *responseWriter {
-	return &responseWriter{ResponseWriter: w}
+	return &responseWriter{ResponseWriter: w, status: http.StatusOK}
 }
 
 func (rw *responseWriter) Status() int {
@@ -67,32 +59,26 @@ func (rw *responseWriter) WriteHeader(code int) {
 	rw.wroteHeader = true
 }
 
-func loggingMiddleware(h http.Handler) http.Handler {
-	return http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
-		defer func() {
-			if err := recover(); err != nil {
-				rw.WriteHeader(http.StatusInternalServerError)
-				log.Printf("{\"err\"}")
-			}
-		}()
-
-		start := time.Now()
-		info := HTTPRequestInfo{
-			Method:    r.Method,
-			URL:       r.URL.String(),
-			Referer:   r.Referer(),
-			UserAgent: r.UserAgent(),
-		}
-		wrapped := wrapResponseWriter(rw)
-		h.ServeHTTP(wrapped, r)
-		info.Duration = float64(time.Since(start)) / float64(time.Second)
-		b, err := json.Marshal(info)
-		if err != nil {
-			rw.WriteHeader(http.StatusInternalServerError)
-			return
-		}
-		log.Print(string(b))
-	})
+// Write implicitly sends a 200, like the stdlib http.ResponseWriter does,
+// so Status() still reports it for handlers that never call WriteHeader.
+func (rw *responseWriter) Write(b []byte) (int, error) {
+	if !rw.wroteHeader {
+		rw.WriteHeader(http.StatusOK)
+	}
+	return rw.ResponseWriter.Write(b)
+}
+
+// Middleware wraps an http.Handler with additional behavior, composable
+// via chain in the order they should run.
+type Middleware func(http.Handler) http.Handler
+
+// chain applies middlewares to h in order, so chain(h, a, b) runs a then
+// b before h handles the request.
+func chain(h http.Handler, middlewares ...Middleware) http.Handler {
+	for i := len(middlewares) - 1; i >= 0; i-- {
+		h = middlewares[i](h)
+	}
+	return h
 }
 
 func main() {
@@ -103,11 +89,17 @@ func main() {
 		loggingLevel = aws.LogLevelType(l)
 	}
 	mux := http.NewServeMux()
+	callerCache := newCallerCache()
+	auth := authMiddleware(callerCache, loggingLevel)
+	requestIDGen := newRequestIDGenerator(requestIDPrefixFromEnv())
 
 	mux.Handle("/", http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
 		rw.Write([]byte("WORKING"))
 	}))
 
+	mux.Handle("/simulate-principal-policy", chain(simulatePrincipalPolicyHandler(loggingLevel), auth))
+	mux.Handle("/simulate-custom-policy", chain(simulateCustomPolicyHandler(loggingLevel), auth))
+
 	mux.Handle("/identity", http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
 		s, err := session.NewSession(
 			&aws.Config{
@@ -120,6 +112,7 @@ func main() {
 			errorHandler(rw, err)
 			return
 		}
+		instrumentSession(s)
 
 		svc := sts.New(s)
 		input := &sts.GetCallerIdentityInput{}
@@ -133,9 +126,14 @@ func main() {
 		json.NewEncoder(rw).Encode(result)
 	}))
 
+	mux.Handle("/assume-role", assumeRoleHandler(loggingLevel))
+	mux.Handle("/assume-role-web-identity", assumeRoleWebIdentityHandler(loggingLevel))
+
+	mux.Handle("/metrics", promhttp.Handler())
+
 	srv := http.Server{
 		Addr:    ":8888",
-		Handler: loggingMiddleware(mux),
+		Handler: chain(mux, structuredLoggingMiddleware(requestIDLog, requestIDGen)),
 	}
 
 	idleConnsClosed := make(chan struct{})
@@ -0,0 +1,110 @@
+package main
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/aws/request"
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+var (
+	awsRequestDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Name: "aws_request_duration_seconds",
+		Help: "Duration of AWS SDK calls, keyed by service, operation and error code.",
+	}, []string{"service", "operation", "aws_error_code"})
+
+	awsRequestRetries = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "aws_request_retries_total",
+		Help: "Number of retries performed for AWS SDK calls, keyed by service and operation.",
+	}, []string{"service", "operation"})
+
+	httpRequestDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Name: "http_request_duration_seconds",
+		Help: "Duration of HTTP requests served by iamtestapp, keyed by method, path and status.",
+	}, []string{"method", "path", "status"})
+)
+
+// httpStatusLabel normalizes a captured response status into the string
+// label used for the status dimension of httpRequestDuration. A status of
+// 0 is reported as 200, the stdlib's implicit status; responses wrapped by
+// wrapResponseWriter already start at 200, so they never report 0.
+func httpStatusLabel(status int) string {
+	if status == 0 {
+		status = http.StatusOK
+	}
+	return strconv.Itoa(status)
+}
+
+// knownHTTPPaths are the routes registered on the mux. The structured
+// logging middleware wraps the whole mux, including unmatched requests,
+// so the path label must be bounded to these or an external scanner
+// probing arbitrary paths creates unbounded label cardinality.
+var knownHTTPPaths = map[string]bool{
+	"/":                          true,
+	"/identity":                  true,
+	"/simulate-principal-policy": true,
+	"/simulate-custom-policy":    true,
+	"/assume-role":               true,
+	"/assume-role-web-identity":  true,
+	"/metrics":                   true,
+}
+
+// httpPathLabel collapses any path not registered on the mux into a
+// single "other" bucket.
+func httpPathLabel(path string) string {
+	if knownHTTPPaths[path] {
+		return path
+	}
+	return "other"
+}
+
+// knownHTTPMethods bounds the method label the same way httpPathLabel
+// bounds the path label.
+var knownHTTPMethods = map[string]bool{
+	http.MethodGet:     true,
+	http.MethodPost:    true,
+	http.MethodPut:     true,
+	http.MethodPatch:   true,
+	http.MethodDelete:  true,
+	http.MethodHead:    true,
+	http.MethodOptions: true,
+}
+
+// httpMethodLabel collapses any non-standard method into "OTHER".
+func httpMethodLabel(method string) string {
+	if knownHTTPMethods[method] {
+		return method
+	}
+	return "OTHER"
+}
+
+// instrumentSession attaches Send/Complete handlers to s so every AWS SDK
+// call made through any service client built from it reports duration and
+// retry metrics automatically, including endpoints added later.
+func instrumentSession(s *session.Session) {
+	s.Handlers.Send.PushBack(func(r *request.Request) {
+		if r.RetryCount > 0 {
+			awsRequestRetries.WithLabelValues(r.ClientInfo.ServiceName, r.Operation.Name).Inc()
+		}
+	})
+
+	s.Handlers.Complete.PushBack(func(r *request.Request) {
+		errorCode := ""
+		if r.Error != nil {
+			if aerr, ok := r.Error.(awserr.Error); ok {
+				errorCode = aerr.Code()
+			} else {
+				errorCode = "internal"
+			}
+		}
+
+		awsRequestDuration.
+			WithLabelValues(r.ClientInfo.ServiceName, r.Operation.Name, errorCode).
+			Observe(time.Since(r.Time).Seconds())
+	})
+}